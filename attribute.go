@@ -0,0 +1,98 @@
+package conntrack
+
+// Top-level CTA_* attribute types, as defined by
+// include/uapi/linux/netfilter/nfnetlink_conntrack.h.
+const (
+	ctaUnspec = iota
+	ctaTupleOrig
+	ctaTupleReply
+	ctaStatus
+	ctaProtoInfo
+	ctaHelp
+	ctaNatSrc
+	ctaTimeout
+	ctaMark
+	ctaCountersOrig
+	ctaCountersReply
+	ctaUse
+	ctaID
+	ctaNatDst
+	ctaTupleMaster
+	ctaSeqAdjOrig
+	ctaSeqAdjReply
+	ctaSecmark
+	ctaZone
+	ctaSecCtx
+	ctaTimestamp
+	ctaMarkMask
+	ctaLabels
+	ctaLabelsMask
+	ctaSynProxy
+	ctaFilter
+	ctaStatusMask
+)
+
+// CTA_TUPLE_* nested attribute types.
+const (
+	ctaTupleUnspec = iota
+	ctaTupleIP
+	ctaTupleProto
+	ctaTupleZone
+)
+
+// CTA_IP_* nested attribute types.
+const (
+	ctaIPUnspec = iota
+	ctaIPv4Src
+	ctaIPv4Dst
+	ctaIPv6Src
+	ctaIPv6Dst
+)
+
+// CTA_PROTO_* nested attribute types.
+const (
+	ctaProtoUnspec = iota
+	ctaProtoNum
+	ctaProtoSrcPort
+	ctaProtoDstPort
+)
+
+// CTA_COUNTERS_* nested attribute types.
+const (
+	ctaCountersUnspec = iota
+	ctaCountersPackets
+	ctaCountersBytes
+)
+
+// CTA_FILTER_* nested attribute types, used to ask the kernel to
+// restrict a dump to flows matching a set of status flags.
+const (
+	ctaFilterUnspec = iota
+	ctaFilterOrigFlags
+	ctaFilterReplyFlags
+)
+
+// CTA_PROTOINFO_* nested attribute types.
+const (
+	ctaProtoInfoUnspec = iota
+	ctaProtoInfoTCP
+	ctaProtoInfoDCCP
+	ctaProtoInfoSCTP
+)
+
+// CTA_PROTOINFO_TCP_* nested attribute types.
+const (
+	ctaProtoInfoTCPUnspec = iota
+	ctaProtoInfoTCPState
+)
+
+// IPS_* status flags, as defined by include/uapi/linux/netfilter/nf_conntrack_common.h.
+const (
+	IPSExpected  = 1 << 0
+	IPSSeenReply = 1 << 1
+	IPSAssured   = 1 << 2
+	IPSConfirmed = 1 << 3
+	IPSSrcNat    = 1 << 4
+	IPSDstNat    = 1 << 5
+	IPSDying     = 1 << 9
+)
@@ -0,0 +1,105 @@
+package conntrack
+
+import (
+	"github.com/mdlayher/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func (c *Conn) payloadV2(f FlowV2) ([]byte, error) {
+	attrs, err := f.marshal()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling flow")
+	}
+	hdr := nfgenmsg{family: f.Family}.marshal()
+	return append(hdr, attrs...), nil
+}
+
+// CreateV2 is Create for FlowV2.
+func (c *Conn) CreateV2(f FlowV2) error {
+	payload, err := c.payloadV2(f)
+	if err != nil {
+		return err
+	}
+
+	flags := netlink.Request | netlink.Acknowledge | netlink.Create | netlink.Excl
+	_, err = c.execute(ipctnlMsgCtNew, uint16(flags), payload)
+	return err
+}
+
+// UpdateV2 is Update for FlowV2.
+func (c *Conn) UpdateV2(f FlowV2) error {
+	payload, err := c.payloadV2(f)
+	if err != nil {
+		return err
+	}
+
+	flags := netlink.Request | netlink.Acknowledge | netlink.Replace
+	_, err = c.execute(ipctnlMsgCtNew, uint16(flags), payload)
+	return err
+}
+
+// DeleteV2 is Delete for FlowV2.
+func (c *Conn) DeleteV2(f FlowV2) error {
+	payload, err := c.payloadV2(f)
+	if err != nil {
+		return err
+	}
+
+	flags := netlink.Request | netlink.Acknowledge
+	_, err = c.execute(ipctnlMsgCtDelete, uint16(flags), payload)
+	return err
+}
+
+// GetV2 is Get for FlowV2.
+func (c *Conn) GetV2(f FlowV2) (FlowV2, error) {
+	payload, err := c.payloadV2(f)
+	if err != nil {
+		return FlowV2{}, err
+	}
+
+	flags := netlink.Request | netlink.Acknowledge
+	msgs, err := c.execute(ipctnlMsgCtGet, uint16(flags), payload)
+	if err != nil {
+		return FlowV2{}, err
+	}
+	if len(msgs) == 0 {
+		return FlowV2{}, errors.Wrap(unix.ENOENT, "flow not found")
+	}
+
+	return unmarshalFlowV2(f.Family, msgs[0].Data[4:])
+}
+
+// DumpV2 is Dump for FlowV2: it returns every flow currently in the
+// conntrack table, decoded without per-tuple net.IP allocation.
+func (c *Conn) DumpV2() ([]FlowV2, error) {
+	return c.DumpFilterV2(Filter{})
+}
+
+// DumpFilterV2 is DumpFilter for FlowV2.
+func (c *Conn) DumpFilterV2(filter Filter) ([]FlowV2, error) {
+	hdr := nfgenmsg{family: unix.AF_UNSPEC}.marshal()
+
+	flags := netlink.Request | netlink.Dump
+	msgs, err := c.execute(ipctnlMsgCtGet, uint16(flags), hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]FlowV2, 0, len(msgs))
+	for _, m := range msgs {
+		if len(m.Data) < 4 {
+			continue
+		}
+		f, err := unmarshalFlowV2(m.Data[0], m.Data[4:])
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshaling flow")
+		}
+		if filter.Mask != 0 && f.Mark&filter.Mask != filter.Mark {
+			continue
+		}
+		flows = append(flows, f)
+	}
+
+	return flows, nil
+}
@@ -0,0 +1,6 @@
+// Package conntrack provides access to Linux conntrack, the connection
+// tracking subsystem underpinning netfilter/iptables, via netlink.
+//
+// It allows callers to create, update, delete, query and dump flows from
+// the kernel's connection tracking table.
+package conntrack
@@ -0,0 +1,140 @@
+package conntrack
+
+import (
+	stderrors "errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdlayher/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// eventRcvBuf is the socket receive buffer size requested for the
+// multicast listening socket. Conntrack event volume can be bursty, and a
+// small default kernel buffer makes ENOBUFS drops common under load.
+const eventRcvBuf = 4 << 20 // 4 MiB
+
+// Event describes a single change to a flow observed on a conntrack
+// multicast group.
+type Event struct {
+	Type      EventType
+	Flow      Flow
+	Timestamp time.Time
+}
+
+// Listen subscribes to groups and delivers decoded events to handler,
+// fanned out across workers goroutines. It returns once the subscription
+// socket is established; events are delivered asynchronously until the
+// Conn is closed. Calling Close on c unblocks the receiver goroutine and
+// stops delivery.
+//
+// If the kernel drops events because the socket's receive buffer
+// overflowed, Listen keeps running and the drop is recorded; callers can
+// inspect it via DroppedEvents.
+func (c *Conn) Listen(groups []NetlinkGroup, workers int, handler func(Event)) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	econn, err := netlink.Dial(unix.NETLINK_NETFILTER, nil)
+	if err != nil {
+		return errors.Wrap(err, "dialing event socket")
+	}
+
+	for _, g := range groups {
+		if err := econn.JoinGroup(uint32(g)); err != nil {
+			econn.Close()
+			return errors.Wrap(err, "joining multicast group")
+		}
+	}
+
+	// Note: NETLINK_NO_ENOBUFS is deliberately not set here. It tells the
+	// kernel to stop reporting multicast buffer overruns as ENOBUFS at
+	// all, which would make the drop accounting below permanently read
+	// zero instead of detecting the drops it exists to count.
+	//
+	// Best-effort: a larger buffer reduces the odds of hitting ENOBUFS in
+	// the first place. Lack of CAP_NET_ADMIN (SO_RCVBUFFORCE) isn't fatal.
+	_ = setRcvBufForce(econn, eventRcvBuf)
+
+	c.event = econn
+
+	msgs := make(chan netlink.Message, workers*16)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for m := range msgs {
+				evt, err := unmarshalEvent(m)
+				if err != nil {
+					continue
+				}
+				handler(evt)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(msgs)
+		for {
+			received, err := econn.Receive()
+			if err != nil {
+				// *netlink.OpError only implements the stdlib Unwrap
+				// contract, not pkg/errors' Cause, so ENOBUFS must be
+				// matched with errors.Is rather than errors.Cause.
+				if stderrors.Is(err, unix.ENOBUFS) {
+					atomic.AddUint64(&c.dropped, 1)
+					continue
+				}
+				return
+			}
+			for _, m := range received {
+				msgs <- m
+			}
+		}
+	}()
+
+	return nil
+}
+
+// DroppedEvents returns the number of times so far that the event socket's
+// receive buffer overflowed (ENOBUFS). Each overflow may have dropped any
+// number of events; the count is of overflows, not of lost events.
+func (c *Conn) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// eventTypeFromHeader maps a netlink message's conntrack subtype to an
+// EventType. The kernel reuses IPCTNL_MSG_CT_NEW for both flow creation and
+// keepalive updates; we report both as EventUpdate except for the very
+// first message for a given flow, which arrives with NLM_F_CREATE set.
+func eventTypeFromHeader(h netlink.Header) EventType {
+	switch h.Type & 0xff {
+	case ipctnlMsgCtNew:
+		if h.Flags&netlink.Create != 0 {
+			return EventNew
+		}
+		return EventUpdate
+	case ipctnlMsgCtDelete:
+		return EventDestroy
+	default:
+		return EventUpdate
+	}
+}
+
+func unmarshalEvent(m netlink.Message) (Event, error) {
+	if len(m.Data) < 4 {
+		return Event{}, errors.New("not a conntrack event message")
+	}
+
+	f, err := unmarshalFlow(m.Data[0], m.Data[4:])
+	if err != nil {
+		return Event{}, errors.Wrap(err, "unmarshaling flow")
+	}
+
+	return Event{Type: eventTypeFromHeader(m.Header), Flow: f, Timestamp: time.Now()}, nil
+}
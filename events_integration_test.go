@@ -0,0 +1,64 @@
+//+build integration
+
+package conntrack
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Listens for events on one Conn while creating and deleting a flow on
+// another, and asserts both the create and destroy are observed.
+func TestConnListenEvents(t *testing.T) {
+
+	// listener and c must share a network namespace, or the flows c
+	// creates/deletes below will never be visible to listener's multicast
+	// socket; makeNSConnPair unshares once and dials both into it, unlike
+	// two separate makeNSConn calls which would each land in their own
+	// distinct anonymous netns.
+	listener, c, err := makeNSConnPair()
+	require.NoError(t, err)
+	defer listener.Close()
+	defer c.Close()
+
+	events := make(chan Event, 16)
+	err = listener.Listen(
+		[]NetlinkGroup{NetlinkGroupCTNew, NetlinkGroupCTUpdate, NetlinkGroupCTDestroy},
+		1,
+		func(e Event) { events <- e },
+	)
+	require.NoError(t, err, "starting event listener")
+
+	f := NewFlow(
+		17, 0,
+		net.ParseIP("1.2.3.4"),
+		net.ParseIP("5.6.7.8"),
+		1234, 5678, 120, 0,
+	)
+
+	require.NoError(t, c.Create(f), "creating flow")
+	require.NoError(t, c.Delete(f), "deleting flow")
+
+	var sawNew, sawDestroy bool
+	deadline := time.After(5 * time.Second)
+	for !sawNew || !sawDestroy {
+		select {
+		case e := <-events:
+			switch e.Type {
+			case EventNew:
+				sawNew = true
+			case EventDestroy:
+				sawDestroy = true
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for events: sawNew=%v sawDestroy=%v", sawNew, sawDestroy)
+		}
+	}
+
+	assert.True(t, sawNew, "expected a new-flow event")
+	assert.True(t, sawDestroy, "expected a destroy-flow event")
+}
@@ -0,0 +1,118 @@
+package conntrack
+
+import (
+	"net"
+
+	"github.com/mdlayher/netlink"
+)
+
+// Filter restricts the results of Conn.DumpFilter to flows whose connmark,
+// once masked with Mask, equals Mark.
+type Filter struct {
+	Mark uint32
+	Mask uint32
+}
+
+// PortRange restricts a predicate to ports in [Min, Max]. A zero-value
+// PortRange matches any port.
+type PortRange struct {
+	Min uint16
+	Max uint16
+}
+
+func (r PortRange) matches(port uint16) bool {
+	if r.Min == 0 && r.Max == 0 {
+		return true
+	}
+	return port >= r.Min && port <= r.Max
+}
+
+// AdvancedFilter restricts the results of Conn.DumpAdvancedFilter by any
+// combination of its fields; a zero value for a field means "don't filter
+// on this". Family, Zone and StatusMask/StatusValue are encoded into the
+// netlink dump request itself (the nfgenmsg header's family, CTA_ZONE, and
+// CTA_FILTER's orig/reply flags, the last requiring Linux >= 4.19), so
+// kernels that support them do the
+// filtering server-side; every field, including those three, is also
+// re-applied client-side after decoding in matches, both to cover fields
+// CTA_FILTER doesn't carry (tuple, ports, TCP state) and to stay correct
+// against kernels that silently ignore CTA_FILTER.
+type AdvancedFilter struct {
+	// Family restricts the dump to unix.AF_INET or unix.AF_INET6 flows.
+	Family uint8
+
+	// Protocol restricts flows to an IANA L4 protocol number, e.g. 6 (TCP)
+	// or 17 (UDP).
+	Protocol uint8
+
+	SourceAddress      *net.IPNet
+	DestinationAddress *net.IPNet
+
+	SourcePort      PortRange
+	DestinationPort PortRange
+
+	// Zone restricts the dump to a single conntrack zone.
+	Zone    uint16
+	HasZone bool
+
+	// StatusMask/StatusValue match flows where Status&StatusMask ==
+	// StatusValue&StatusMask, e.g. {StatusMask: IPSAssured, StatusValue:
+	// IPSAssured} for established flows.
+	StatusMask  uint32
+	StatusValue uint32
+
+	// TCPState, if non-nil, restricts flows to TCP connections in this
+	// state (see TCPInfo.State). Non-TCP flows never match.
+	TCPState *uint8
+}
+
+// encode adds the dump-request attributes the kernel can filter on
+// directly: CTA_ZONE for Zone, and CTA_FILTER's orig/reply flags for
+// StatusMask/StatusValue. Kernels predating CTA_FILTER (< 4.19) ignore the
+// unknown attribute and return every flow; matches is always applied
+// afterward to cover that case.
+func (f AdvancedFilter) encode(ae *netlink.AttributeEncoder) {
+	if f.HasZone {
+		ae.Uint16(ctaZone, f.Zone)
+	}
+	if f.StatusMask != 0 {
+		ae.Nested(ctaFilter, func(fae *netlink.AttributeEncoder) error {
+			fae.Uint32(ctaFilterOrigFlags, f.StatusValue&f.StatusMask)
+			fae.Uint32(ctaFilterReplyFlags, f.StatusValue&f.StatusMask)
+			return nil
+		})
+	}
+}
+
+func (f AdvancedFilter) matches(flow Flow) bool {
+	if f.Family != 0 && flow.Family != f.Family {
+		return false
+	}
+	if f.Protocol != 0 && flow.TupleOrig.Proto.Protocol != f.Protocol {
+		return false
+	}
+	if f.SourceAddress != nil && !f.SourceAddress.Contains(flow.TupleOrig.IP.SourceAddress) {
+		return false
+	}
+	if f.DestinationAddress != nil && !f.DestinationAddress.Contains(flow.TupleOrig.IP.DestinationAddress) {
+		return false
+	}
+	if !f.SourcePort.matches(flow.TupleOrig.Proto.SourcePort) {
+		return false
+	}
+	if !f.DestinationPort.matches(flow.TupleOrig.Proto.DestinationPort) {
+		return false
+	}
+	if f.HasZone && flow.Zone != f.Zone {
+		return false
+	}
+	if f.StatusMask != 0 && flow.Status&f.StatusMask != f.StatusValue&f.StatusMask {
+		return false
+	}
+	if f.TCPState != nil {
+		if flow.TCP == nil || flow.TCP.State != *f.TCPState {
+			return false
+		}
+	}
+	return true
+}
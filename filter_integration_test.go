@@ -0,0 +1,53 @@
+//+build integration
+
+package conntrack
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// Creates a mix of TCP/UDP and IPv4/IPv6 flows and asserts that each
+// AdvancedFilter predicate returns exactly the matching subset.
+func TestConnDumpAdvancedFilter(t *testing.T) {
+
+	c, err := makeNSConn()
+	require.NoError(t, err)
+
+	tcpV4 := NewFlow(6, 0, net.ParseIP("1.2.3.4"), net.ParseIP("5.6.7.8"), 1111, 80, 120, 0)
+	udpV4 := NewFlow(17, 0, net.ParseIP("1.2.3.5"), net.ParseIP("5.6.7.9"), 2222, 53, 120, 0)
+	tcpV6 := NewFlow(6, 0, net.ParseIP("2a00:1450:400e:804::200e"), net.ParseIP("2a00:1450:400e:804::200f"), 3333, 443, 120, 0)
+	udpV6 := NewFlow(17, 0, net.ParseIP("2a00:1450:400e:804::300e"), net.ParseIP("2a00:1450:400e:804::300f"), 4444, 53, 120, 0)
+
+	for _, f := range []Flow{tcpV4, udpV4, tcpV6, udpV6} {
+		require.NoError(t, c.Create(f), "creating flow")
+	}
+	defer c.Flush()
+
+	v4, err := c.DumpAdvancedFilter(AdvancedFilter{Family: unix.AF_INET})
+	require.NoError(t, err)
+	assert.Len(t, v4, 2)
+
+	v6, err := c.DumpAdvancedFilter(AdvancedFilter{Family: unix.AF_INET6})
+	require.NoError(t, err)
+	assert.Len(t, v6, 2)
+
+	tcpOnly, err := c.DumpAdvancedFilter(AdvancedFilter{Protocol: 6})
+	require.NoError(t, err)
+	assert.Len(t, tcpOnly, 2)
+
+	portMatch, err := c.DumpAdvancedFilter(AdvancedFilter{SourcePort: PortRange{Min: 2222, Max: 2222}})
+	require.NoError(t, err)
+	require.Len(t, portMatch, 1)
+	assert.Equal(t, udpV4.TupleOrig.IP.SourceAddress.String(), portMatch[0].TupleOrig.IP.SourceAddress.String())
+
+	_, cidr, err := net.ParseCIDR("1.2.3.0/24")
+	require.NoError(t, err)
+	cidrMatch, err := c.DumpAdvancedFilter(AdvancedFilter{SourceAddress: cidr})
+	require.NoError(t, err)
+	assert.Len(t, cidrMatch, 2)
+}
@@ -0,0 +1,266 @@
+package conntrack
+
+import (
+	"net"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// IPTuple holds the source and destination address of one direction of a
+// flow.
+type IPTuple struct {
+	SourceAddress      net.IP
+	DestinationAddress net.IP
+}
+
+// ProtoTuple holds the transport-layer protocol number and ports of one
+// direction of a flow.
+type ProtoTuple struct {
+	Protocol        uint8
+	SourcePort      uint16
+	DestinationPort uint16
+}
+
+// Tuple combines the network and transport layer identifiers of one
+// direction of a flow.
+type Tuple struct {
+	IP    IPTuple
+	Proto ProtoTuple
+}
+
+// Counter holds the packet and byte counters conntrack keeps for one
+// direction of a flow.
+type Counter struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// Flow represents a single entry in the conntrack table.
+type Flow struct {
+	Family uint8
+
+	TupleOrig   Tuple
+	TupleReply  Tuple
+	TupleMaster Tuple
+
+	Status  uint32
+	Timeout uint32
+	Mark    uint32
+	Zone    uint16
+
+	CounterOrig  Counter
+	CounterReply Counter
+
+	// TCP holds TCP-specific protocol state. It is nil for non-TCP flows
+	// or when the kernel didn't report CTA_PROTOINFO.
+	TCP *TCPInfo
+}
+
+// TCPInfo holds the TCP connection state tracked for a flow, as reported
+// under CTA_PROTOINFO.
+type TCPInfo struct {
+	State uint8
+}
+
+// NewFlow builds a Flow ready to be passed to Conn.Create. proto is the
+// IANA transport protocol number (e.g. 6 for TCP, 17 for UDP). timeout is
+// in seconds and mark is the initial connmark.
+func NewFlow(proto uint8, status uint32, src, dst net.IP, srcPort, dstPort uint16, timeout, mark uint32) Flow {
+	orig := Tuple{
+		IP:    IPTuple{SourceAddress: src, DestinationAddress: dst},
+		Proto: ProtoTuple{Protocol: proto, SourcePort: srcPort, DestinationPort: dstPort},
+	}
+	reply := Tuple{
+		IP:    IPTuple{SourceAddress: dst, DestinationAddress: src},
+		Proto: ProtoTuple{Protocol: proto, SourcePort: dstPort, DestinationPort: srcPort},
+	}
+
+	family := uint8(unix.AF_INET)
+	if src.To4() == nil {
+		family = unix.AF_INET6
+	}
+
+	return Flow{
+		Family:     family,
+		TupleOrig:  orig,
+		TupleReply: reply,
+		Status:     status,
+		Timeout:    timeout,
+		Mark:       mark,
+	}
+}
+
+func marshalIPTuple(ae *netlink.AttributeEncoder, t IPTuple) {
+	if v4 := t.SourceAddress.To4(); v4 != nil {
+		ae.Bytes(ctaIPv4Src, v4)
+		ae.Bytes(ctaIPv4Dst, t.DestinationAddress.To4())
+		return
+	}
+	ae.Bytes(ctaIPv6Src, t.SourceAddress.To16())
+	ae.Bytes(ctaIPv6Dst, t.DestinationAddress.To16())
+}
+
+func marshalProtoTuple(ae *netlink.AttributeEncoder, t ProtoTuple) {
+	ae.Uint8(ctaProtoNum, t.Protocol)
+	ae.Uint16(ctaProtoSrcPort, htons(t.SourcePort))
+	ae.Uint16(ctaProtoDstPort, htons(t.DestinationPort))
+}
+
+func marshalTuple(ae *netlink.AttributeEncoder, typ uint16, t Tuple) {
+	ae.Nested(typ, func(nae *netlink.AttributeEncoder) error {
+		nae.Nested(ctaTupleIP, func(iae *netlink.AttributeEncoder) error {
+			marshalIPTuple(iae, t.IP)
+			return nil
+		})
+		nae.Nested(ctaTupleProto, func(pae *netlink.AttributeEncoder) error {
+			marshalProtoTuple(pae, t.Proto)
+			return nil
+		})
+		return nil
+	})
+}
+
+// marshal encodes f into the attributes of a conntrack netlink request.
+func (f Flow) marshal() ([]byte, error) {
+	ae := netlink.NewAttributeEncoder()
+
+	marshalTuple(ae, ctaTupleOrig, f.TupleOrig)
+	marshalTuple(ae, ctaTupleReply, f.TupleReply)
+
+	ae.Uint32(ctaStatus, f.Status)
+	ae.Uint32(ctaTimeout, f.Timeout)
+	if f.Mark != 0 {
+		ae.Uint32(ctaMark, f.Mark)
+	}
+
+	return ae.Encode()
+}
+
+func unmarshalIPTuple(ad *netlink.AttributeDecoder) IPTuple {
+	var t IPTuple
+	for ad.Next() {
+		switch ad.Type() {
+		case ctaIPv4Src, ctaIPv6Src:
+			t.SourceAddress = net.IP(ad.Bytes())
+		case ctaIPv4Dst, ctaIPv6Dst:
+			t.DestinationAddress = net.IP(ad.Bytes())
+		}
+	}
+	return t
+}
+
+func unmarshalProtoTuple(ad *netlink.AttributeDecoder) ProtoTuple {
+	var t ProtoTuple
+	for ad.Next() {
+		switch ad.Type() {
+		case ctaProtoNum:
+			t.Protocol = ad.Uint8()
+		case ctaProtoSrcPort:
+			t.SourcePort = htons(ad.Uint16())
+		case ctaProtoDstPort:
+			t.DestinationPort = htons(ad.Uint16())
+		}
+	}
+	return t
+}
+
+func unmarshalTuple(ad *netlink.AttributeDecoder) Tuple {
+	var t Tuple
+	for ad.Next() {
+		switch ad.Type() {
+		case ctaTupleIP:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				t.IP = unmarshalIPTuple(nad)
+				return nil
+			})
+		case ctaTupleProto:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				t.Proto = unmarshalProtoTuple(nad)
+				return nil
+			})
+		}
+	}
+	return t
+}
+
+func unmarshalCounter(ad *netlink.AttributeDecoder) Counter {
+	var c Counter
+	for ad.Next() {
+		switch ad.Type() {
+		case ctaCountersPackets:
+			c.Packets = ad.Uint64()
+		case ctaCountersBytes:
+			c.Bytes = ad.Uint64()
+		}
+	}
+	return c
+}
+
+// unmarshalFlow decodes the attributes of a conntrack netlink message
+// (following the 4-byte nfgenmsg header) into a Flow.
+func unmarshalFlow(family uint8, b []byte) (Flow, error) {
+	f := Flow{Family: family}
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return Flow{}, err
+	}
+
+	for ad.Next() {
+		switch ad.Type() {
+		case ctaTupleOrig:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				f.TupleOrig = unmarshalTuple(nad)
+				return nil
+			})
+		case ctaTupleReply:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				f.TupleReply = unmarshalTuple(nad)
+				return nil
+			})
+		case ctaTupleMaster:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				f.TupleMaster = unmarshalTuple(nad)
+				return nil
+			})
+		case ctaStatus:
+			f.Status = ad.Uint32()
+		case ctaTimeout:
+			f.Timeout = ad.Uint32()
+		case ctaMark:
+			f.Mark = ad.Uint32()
+		case ctaZone:
+			f.Zone = ad.Uint16()
+		case ctaCountersOrig:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				f.CounterOrig = unmarshalCounter(nad)
+				return nil
+			})
+		case ctaCountersReply:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				f.CounterReply = unmarshalCounter(nad)
+				return nil
+			})
+		case ctaProtoInfo:
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					if nad.Type() != ctaProtoInfoTCP {
+						continue
+					}
+					nad.Nested(func(tad *netlink.AttributeDecoder) error {
+						for tad.Next() {
+							if tad.Type() == ctaProtoInfoTCPState {
+								f.TCP = &TCPInfo{State: tad.Uint8()}
+							}
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+	}
+
+	return f, ad.Err()
+}
@@ -188,6 +188,49 @@ func TestConnDumpFilter(t *testing.T) {
 	assert.Len(t, d, len(flows))
 }
 
+// Creates a batch of flows where one entry duplicates an already-existing
+// flow, which the kernel rejects with EEXIST because CreateBatch requests
+// NLM_F_EXCL. Asserts the failure is reported for that entry's index alone
+// and every other flow in the batch still lands in the table.
+func TestConnCreateBatchPartialFailure(t *testing.T) {
+
+	c, err := makeNSConn()
+	require.NoError(t, err)
+
+	defer func() {
+		err = c.Flush()
+		assert.NoError(t, err, "error flushing table")
+	}()
+
+	dup := NewFlow(6, 0, net.IPv4(1, 2, 3, 4), net.IPv4(5, 6, 7, 8), 1234, 1, 120, 0)
+	err = c.Create(dup)
+	require.NoError(t, err, "creating flow that the batch will collide with")
+
+	const batchSize = 16
+	const dupIndex = 7
+	flows := make([]Flow, batchSize)
+	for i := range flows {
+		if i == dupIndex {
+			flows[i] = dup
+			continue
+		}
+		flows[i] = NewFlow(6, 0, net.IPv4(1, 2, 3, 4), net.IPv4(5, 6, 7, 8), 1234, uint16(100+i), 120, 0)
+	}
+
+	err = c.CreateBatch(flows)
+	require.Error(t, err, "expected a partial batch failure")
+
+	batchErrs, ok := err.(BatchErrors)
+	require.True(t, ok, "expected a BatchErrors, got %T: %s", err, err)
+	require.Len(t, batchErrs, 1, "expected exactly one failing flow")
+	assert.Equal(t, dupIndex, batchErrs[0].Index)
+	assert.EqualError(t, batchErrs[0].Err, unix.EEXIST.Error())
+
+	dumped, err := c.Dump()
+	require.NoError(t, err, "dumping table")
+	assert.Len(t, dumped, batchSize, "expected every non-duplicate flow in the batch to have been created")
+}
+
 // Bench scenario that calls Conn.Create and Conn.Delete on the same Flow once per iteration.
 // This includes two marshaling operations for create/delete, two syscalls and output validation.
 func BenchmarkCreateDeleteFlow(b *testing.B) {
@@ -212,3 +255,31 @@ func BenchmarkCreateDeleteFlow(b *testing.B) {
 		}
 	}
 }
+
+// Bench scenario that installs and removes 10k flows per iteration via
+// CreateBatch/DeleteBatch, for comparison against BenchmarkCreateDeleteFlow's
+// one-syscall-per-flow cost.
+func BenchmarkCreateDeleteBatch(b *testing.B) {
+
+	b.ReportAllocs()
+
+	c, err := makeNSConn()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	const batchSize = 10000
+	flows := make([]Flow, batchSize)
+	for i := range flows {
+		flows[i] = NewFlow(6, 0, net.IPv4(1, 2, byte(i>>8), byte(i)), net.IPv4(5, 6, byte(i>>8), byte(i)), 1234, uint16(i%65535), 120, 0)
+	}
+
+	for n := 0; n < b.N; n++ {
+		if err := c.CreateBatch(flows); err != nil {
+			b.Fatalf("creating batch %d: %s", n, err)
+		}
+		if err := c.DeleteBatch(flows); err != nil {
+			b.Fatalf("deleting batch %d: %s", n, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+//+build integration
+
+package conntrack
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a flow via the net.IP API and reads it back via the netip.Addr
+// API using ToV2, asserting the source address survives the conversion.
+func TestConnCreateGetFlowV2(t *testing.T) {
+
+	c, err := makeNSConn()
+	require.NoError(t, err)
+
+	f := NewFlow(17, 0, net.ParseIP("1.2.3.4"), net.ParseIP("5.6.7.8"), 1234, 5678, 120, 0)
+	require.NoError(t, c.Create(f), "creating flow")
+
+	qflow, err := c.GetV2(f.ToV2())
+	require.NoError(t, err, "get flow via FlowV2")
+
+	wantSrc, _ := netip.AddrFromSlice(f.TupleOrig.IP.SourceAddress)
+	assert.Equal(t, wantSrc.Unmap(), qflow.TupleOrig.IP.SourceAddress)
+}
+
+const allocBenchFlows = 10000
+
+func seedFlows(tb testing.TB, c *Conn) {
+	for i := 0; i < allocBenchFlows; i++ {
+		f := NewFlow(6, 0, net.IPv4(10, 0, byte(i>>8), byte(i)), net.IPv4(10, 1, byte(i>>8), byte(i)), 1234, uint16(i%65535), 120, 0)
+		if err := c.Create(f); err != nil {
+			tb.Fatalf("seeding flow %d: %s", i, err)
+		}
+	}
+}
+
+// Measures per-call allocations of Dump over a table of 10k flows, for
+// comparison with BenchmarkDumpV2_Allocs.
+func BenchmarkDump_Allocs(b *testing.B) {
+	c, err := makeNSConn()
+	if err != nil {
+		b.Fatal(err)
+	}
+	seedFlows(b, c)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := c.Dump(); err != nil {
+			b.Fatalf("dump %d: %s", n, err)
+		}
+	}
+}
+
+func BenchmarkDumpV2_Allocs(b *testing.B) {
+	c, err := makeNSConn()
+	if err != nil {
+		b.Fatal(err)
+	}
+	seedFlows(b, c)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := c.DumpV2(); err != nil {
+			b.Fatalf("dumpV2 %d: %s", n, err)
+		}
+	}
+}
+
+// DumpV2's decode path (addr4/addr6 in flowv2.go) reads each address
+// directly out of the attribute decoder's raw payload instead of via
+// AttributeDecoder.Bytes, which heap-allocates a copy per call; Dump's
+// decode (unmarshalIPTuple) pays that cost once per address per flow.
+// DumpV2 still allocates for the nested *netlink.AttributeDecoder values
+// needed to walk tuple/IP/proto attributes, so it is not allocation-free,
+// but over a large table it must allocate measurably less than Dump.
+func TestDumpV2_AllocatesLessThanDump(t *testing.T) {
+	c, err := makeNSConn()
+	require.NoError(t, err)
+	seedFlows(t, c)
+	defer c.Flush()
+
+	v1Allocs := testing.AllocsPerRun(5, func() {
+		if _, err := c.Dump(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	v2Allocs := testing.AllocsPerRun(5, func() {
+		if _, err := c.DumpV2(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Logf("Dump: %.0f allocs, DumpV2: %.0f allocs, over %d flows", v1Allocs, v2Allocs, allocBenchFlows)
+	assert.Less(t, v2Allocs, v1Allocs)
+}
@@ -0,0 +1,56 @@
+//+build integration
+
+package conntrack
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// makeNSConn moves the calling goroutine's OS thread into a fresh network
+// namespace and dials a Conn into it, so integration tests can create and
+// destroy flows without disturbing the host's conntrack table.
+func makeNSConn() (*Conn, error) {
+	runtime.LockOSThread()
+
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		return nil, errors.Wrap(err, "unsharing network namespace")
+	}
+
+	c, err := Dial(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "dialing in new network namespace")
+	}
+
+	return c, nil
+}
+
+// makeNSConnPair moves the calling goroutine's OS thread into a single
+// fresh network namespace and dials two Conns into it. Network namespace
+// membership is a per-thread attribute, so calling makeNSConn twice would
+// unshare twice and land each Conn in its own distinct anonymous netns
+// instead of sharing one; tests that need two Conns to observe the same
+// conntrack table (e.g. one listening for events while the other
+// creates/deletes flows) must unshare once and dial both from that thread.
+func makeNSConnPair() (a, b *Conn, err error) {
+	runtime.LockOSThread()
+
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		return nil, nil, errors.Wrap(err, "unsharing network namespace")
+	}
+
+	a, err = Dial(nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "dialing first conn in new network namespace")
+	}
+
+	b, err = Dial(nil)
+	if err != nil {
+		a.Close()
+		return nil, nil, errors.Wrap(err, "dialing second conn in new network namespace")
+	}
+
+	return a, b, nil
+}
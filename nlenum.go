@@ -0,0 +1,52 @@
+package conntrack
+
+// NFNL_SUBSYS_CTNETLINK is the netfilter netlink subsystem used for
+// connection tracking, combined with the message type to form the
+// netlink header's Type field.
+const nfnlSubsysCTNetlink = 1
+
+// Conntrack netlink message types, shifted into the subsystem's message
+// space (subsys<<8 | type) when placed in a netlink header.
+const (
+	ipctnlMsgCtNew    = 0
+	ipctnlMsgCtGet    = 1
+	ipctnlMsgCtDelete = 2
+)
+
+// NetlinkGroup identifies a conntrack multicast group that a Conn can
+// subscribe to via Listen. These are NFNLGRP_CONNTRACK_* group numbers as
+// passed to NETLINK_ADD_MEMBERSHIP, not the legacy nfnetlink_compat.h
+// bitmask.
+type NetlinkGroup uint32
+
+// Conntrack multicast groups, as defined by
+// include/uapi/linux/netfilter/nfnetlink.h's NFNLGRP_CONNTRACK_* enum.
+const (
+	NetlinkGroupCTNew     NetlinkGroup = 1
+	NetlinkGroupCTUpdate  NetlinkGroup = 2
+	NetlinkGroupCTDestroy NetlinkGroup = 3
+)
+
+// EventType identifies the kind of change a conntrack Event represents.
+type EventType uint8
+
+// Event types, corresponding to the conntrack multicast groups they are
+// received on.
+const (
+	EventNew EventType = iota + 1
+	EventUpdate
+	EventDestroy
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventNew:
+		return "NEW"
+	case EventUpdate:
+		return "UPDATE"
+	case EventDestroy:
+		return "DESTROY"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,44 @@
+package conntrack
+
+import (
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// setCapAck asks the kernel to omit the echoed request payload from
+// NLMSG_ERROR acks, leaving just the errno. Batched operations can
+// generate thousands of acks, and the echoed payload is otherwise wasted
+// bandwidth since callers already have the request.
+func setCapAck(c *netlink.Conn) error {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_NETLINK, unix.NETLINK_CAP_ACK, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// setRcvBufForce grows a socket's receive buffer past the system default
+// limit (requires CAP_NET_ADMIN). Callers should treat failure as
+// non-fatal: a smaller buffer only makes ENOBUFS drops more likely, it
+// doesn't break correctness.
+func setRcvBufForce(c *netlink.Conn, bytes int) error {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUFFORCE, bytes)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
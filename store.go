@@ -0,0 +1,240 @@
+package conntrack
+
+import (
+	"sync"
+	"time"
+)
+
+// FlowKey identifies a flow by its original-direction 5-tuple. Unlike
+// Tuple, it is comparable and safe to use as a map key.
+type FlowKey struct {
+	Proto         uint8
+	SourceAddress [16]byte
+	DestAddress   [16]byte
+	SourcePort    uint16
+	DestPort      uint16
+}
+
+// NewFlowKey builds the FlowKey for a tuple, as found in Flow.TupleOrig.
+func NewFlowKey(t Tuple) FlowKey {
+	var k FlowKey
+	k.Proto = t.Proto.Protocol
+	// To16 canonicalizes IPv4 to its 16-byte v4-in-v6 form before copying,
+	// so a kernel-decoded flow (whose CTA_IP_V4_SRC/DST attributes unmarshal
+	// to a 4-byte net.IP) keys identically to one built by NewFlow (whose
+	// address is already a 16-byte net.IP).
+	copy(k.SourceAddress[:], t.IP.SourceAddress.To16())
+	copy(k.DestAddress[:], t.IP.DestinationAddress.To16())
+	k.SourcePort = t.Proto.SourcePort
+	k.DestPort = t.Proto.DestinationPort
+	return k
+}
+
+// TrackedFlow is a Flow as last observed by a Store, augmented with the
+// bookkeeping needed to compute traffic deltas and closure across polls.
+type TrackedFlow struct {
+	Flow
+
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	PrevBytes   uint64
+	PrevPackets uint64
+
+	DeltaBytes   uint64
+	DeltaPackets uint64
+
+	// Closed is set once a flow that was previously tracked fails to
+	// appear in a poll. The TrackedFlow is retained for exactly one poll
+	// cycle with Closed set so callers can observe the final counters,
+	// then removed.
+	Closed bool
+}
+
+// StoreOptions configures a Store.
+type StoreOptions struct {
+	// PollInterval is how often the Store dumps the conntrack table.
+	PollInterval time.Duration
+
+	// Filter, if set, restricts tracking to flows for which it returns
+	// true. It is evaluated after each Dump, so it can inspect any Flow
+	// field (mark, zone, tuple, ...).
+	Filter func(Flow) bool
+}
+
+// Store periodically polls a Conn and maintains the live set of flows as
+// TrackedFlow values with delta counters computed since the previous poll.
+type Store struct {
+	conn *Conn
+	opts StoreOptions
+
+	mu    sync.RWMutex
+	flows map[FlowKey]*TrackedFlow
+	pool  sync.Pool
+
+	changed chan struct{}
+	closeCh chan struct{}
+	closeWg sync.WaitGroup
+}
+
+// NewStore creates a Store polling c on opts.PollInterval and starts its
+// background poll loop. Call Close to stop it.
+func NewStore(c *Conn, opts StoreOptions) *Store {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	s := &Store{
+		conn:    c,
+		opts:    opts,
+		flows:   make(map[FlowKey]*TrackedFlow),
+		pool:    sync.Pool{New: func() interface{} { return new(TrackedFlow) }},
+		changed: make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+
+	s.closeWg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Close stops the Store's poll loop.
+func (s *Store) Close() {
+	close(s.closeCh)
+	s.closeWg.Wait()
+}
+
+// Changed signals, without blocking, whenever a poll observed a new or
+// closed flow. Reads on the channel never deliver more than one pending
+// signal; callers should re-check with ForEach/Get rather than count
+// sends.
+func (s *Store) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Get returns a snapshot of the TrackedFlow for key, if any. The returned
+// pointer is a copy and is never mutated by later polls; poll() recycles
+// the stored TrackedFlow through a pool, so returning that pointer directly
+// would let it be overwritten by an unrelated flow after the next poll.
+func (s *Store) Get(key FlowKey) (*TrackedFlow, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tf, ok := s.flows[key]
+	if !ok {
+		return nil, false
+	}
+	cp := *tf
+	return &cp, true
+}
+
+// ForEach calls fn for every tracked flow, stopping early if fn returns
+// false. fn must not call back into the Store. Each TrackedFlow passed to
+// fn is a snapshot copy, safe to retain past the call; see Get.
+func (s *Store) ForEach(fn func(*TrackedFlow) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, tf := range s.flows {
+		cp := *tf
+		if !fn(&cp) {
+			return
+		}
+	}
+}
+
+func (s *Store) run() {
+	defer s.closeWg.Done()
+
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case <-ticker.C:
+			s.poll()
+		}
+	}
+}
+
+// poll dumps the conntrack table once and reconciles it against the
+// currently tracked flows. It is split out from run so benchmarks and
+// tests can drive it directly without waiting on the ticker.
+func (s *Store) poll() {
+	flows, err := s.conn.Dump()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[FlowKey]bool, len(flows))
+	changed := false
+
+	s.mu.Lock()
+
+	for _, f := range flows {
+		if s.opts.Filter != nil && !s.opts.Filter(f) {
+			continue
+		}
+
+		key := NewFlowKey(f.TupleOrig)
+		seen[key] = true
+
+		tf, ok := s.flows[key]
+		if !ok {
+			tf = s.pool.Get().(*TrackedFlow)
+			*tf = TrackedFlow{FirstSeen: now}
+			s.flows[key] = tf
+			changed = true
+		}
+
+		bytes := f.CounterOrig.Bytes + f.CounterReply.Bytes
+		packets := f.CounterOrig.Packets + f.CounterReply.Packets
+
+		tf.Flow = f
+		tf.LastSeen = now
+		// Counters regress when a flow is deleted and a new one is created
+		// under the same 5-tuple between polls; treat that as a fresh
+		// baseline rather than underflowing the uint64 delta.
+		if bytes >= tf.PrevBytes {
+			tf.DeltaBytes = bytes - tf.PrevBytes
+		} else {
+			tf.DeltaBytes = 0
+		}
+		if packets >= tf.PrevPackets {
+			tf.DeltaPackets = packets - tf.PrevPackets
+		} else {
+			tf.DeltaPackets = 0
+		}
+		tf.PrevBytes = bytes
+		tf.PrevPackets = packets
+		tf.Closed = false
+	}
+
+	for key, tf := range s.flows {
+		if seen[key] {
+			continue
+		}
+		if tf.Closed {
+			// Already reported as closed on the previous poll.
+			delete(s.flows, key)
+			s.pool.Put(tf)
+			continue
+		}
+		tf.Closed = true
+		tf.LastSeen = now
+		tf.DeltaBytes = 0
+		tf.DeltaPackets = 0
+		changed = true
+	}
+
+	s.mu.Unlock()
+
+	if changed {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+}
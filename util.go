@@ -0,0 +1,16 @@
+package conntrack
+
+import (
+	"encoding/binary"
+
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+// htons converts a 16-bit value between host and network byte order. Ports
+// in conntrack attributes are always big-endian regardless of host
+// endianness, so the same function serves both directions.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return nlenc.Uint16(b[:])
+}